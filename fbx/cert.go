@@ -1,8 +1,10 @@
 package fbx
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"net"
 	"net/http"
 	"time"
 )
@@ -66,7 +68,11 @@ tL4ndQavEi51mI38AjEAi/V3bNTIZargCyzuFJ0nN6T5U6VR5CmD1/iQMVtCnwr1
 `
 )
 
-func newTLSConfig() *tls.Config {
+// defaultCertPool returns a pool seeded with the certificates embedded
+// in the binary. newTLSConfig uses it when no TrustStore is configured;
+// TrustStore.Reload does not call it and adds the same embedded
+// certificates to a pool of its own.
+func defaultCertPool() *x509.CertPool {
 	caCertPool := x509.NewCertPool()
 	if !caCertPool.AppendCertsFromPEM([]byte(freeboxRootCA)) {
 		panic("Could not add the freebox ECC certificate")
@@ -74,14 +80,24 @@ func newTLSConfig() *tls.Config {
 	if !caCertPool.AppendCertsFromPEM([]byte(isrgX2rootCA)) {
 		panic("Could not add the ISRG X2 certificate")
 	}
+	return caCertPool
+}
 
+func newTLSConfig() *tls.Config {
 	// Setup HTTPS client
 	tlsConfig := &tls.Config{
-		RootCAs: caCertPool,
+		RootCAs: defaultCertPool(),
 	}
 	return tlsConfig
 }
 
+// NewDefaultTLSConfig returns a *tls.Config trusting just the embedded
+// Freebox and Let's Encrypt roots, for callers outside this package
+// that need a sane default when no TrustStore is configured.
+func NewDefaultTLSConfig() *tls.Config {
+	return newTLSConfig()
+}
+
 func httpClient() HttpClientInternal {
 	return &http.Client{
 		Transport: &http.Transport{
@@ -92,3 +108,23 @@ func httpClient() HttpClientInternal {
 		Timeout: 10 * time.Second,
 	}
 }
+
+// httpClientWithTrustStore is like httpClient but dials using the
+// *x509.CertPool currently held by ts, so rotating the Freebox root or
+// migrating Let's Encrypt chains takes effect without rebuilding the
+// binary or restarting the process.
+func httpClientWithTrustStore(ts *TrustStore) HttpClientInternal {
+	dialer := &tls.Dialer{}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				d := *dialer
+				d.Config = &tls.Config{RootCAs: ts.Pool()}
+				return d.DialContext(ctx, network, addr)
+			},
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     10 * time.Minute,
+		},
+		Timeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,257 @@
+package fbx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/vintzvintz/fbx-exporter/log"
+)
+
+// TrustStore builds the *x509.CertPool used to validate the Freebox's
+// (or any fronting reverse proxy's) TLS certificate, and rebuilds it
+// whenever the on-disk bundle changes. The pool in effect is stored
+// behind an atomic.Pointer so in-flight requests are never blocked on
+// a reload, and httpClientWithTrustStore dials against whatever pool
+// is current at connection time.
+type TrustStore struct {
+	bundlePath    string
+	useSystemPool bool
+
+	pool atomic.Pointer[x509.CertPool]
+
+	mu        sync.Mutex
+	watcher   *fsnotify.Watcher
+	extraPEMs [][]byte // certificates added at runtime via Add
+
+	certCount  prometheus.Gauge
+	lastReload prometheus.Gauge
+}
+
+// NewTrustStore builds a TrustStore seeded with the embedded default
+// certificates, plus the PEM file(s) found at bundlePath (a single
+// file or a directory of .pem/.crt files) when bundlePath is not
+// empty, plus the system root store when useSystemPool is true. When
+// bundlePath is not empty, it is watched with fsnotify (a single file
+// through its parent directory) and the pool is rebuilt whenever it is
+// written, created, removed or renamed.
+func NewTrustStore(bundlePath string, useSystemPool bool) (*TrustStore, error) {
+	ts := &TrustStore{
+		bundlePath:    bundlePath,
+		useSystemPool: useSystemPool,
+		certCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "fbx_truststore_certificates",
+			Help: "Number of CA certificates currently trusted by the exporter.",
+		}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "fbx_truststore_last_reload_timestamp_seconds",
+			Help: "Unix timestamp of the last successful trust store reload.",
+		}),
+	}
+
+	if err := ts.Reload(); err != nil {
+		return nil, err
+	}
+
+	if bundlePath != "" {
+		if err := ts.watch(); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+// Pool returns the *x509.CertPool currently in effect.
+func (ts *TrustStore) Pool() *x509.CertPool {
+	return ts.pool.Load()
+}
+
+// TLSConfig returns a *tls.Config trusting the pool currently in
+// effect. Callers that dial repeatedly (e.g. the websocket subsystem's
+// reconnect loop) should call this again on every dial rather than
+// caching the result, so a reload is picked up on the next dial.
+func (ts *TrustStore) TLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: ts.Pool()}
+}
+
+// Add appends a PEM-encoded certificate (or bundle) to the pool in
+// effect without touching the on-disk bundle. It is meant for
+// programmatic callers, e.g. the admin API adding a certificate ahead
+// of a scheduled rotation. The certificate survives a later Reload.
+func (ts *TrustStore) Add(pem []byte) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	pool := ts.Pool().Clone()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("fbx: no valid certificate found in PEM data")
+	}
+	ts.extraPEMs = append(ts.extraPEMs, pem)
+	ts.swap(pool)
+	return nil
+}
+
+// Reload rebuilds the pool from scratch: the system pool (if
+// configured) or an empty one, with the embedded defaults, the
+// on-disk bundle and any certificate added at runtime layered on top.
+func (ts *TrustStore) Reload() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var pool *x509.CertPool
+	if ts.useSystemPool {
+		sysPool, err := x509.SystemCertPool()
+		if err != nil {
+			return err
+		}
+		pool = sysPool
+	} else {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM([]byte(freeboxRootCA)) {
+		return fmt.Errorf("fbx: could not add the embedded Freebox root certificate")
+	}
+	if !pool.AppendCertsFromPEM([]byte(isrgX2rootCA)) {
+		return fmt.Errorf("fbx: could not add the embedded ISRG X2 certificate")
+	}
+
+	if ts.bundlePath != "" {
+		if err := addBundlePath(pool, ts.bundlePath); err != nil {
+			return err
+		}
+	}
+
+	for _, pem := range ts.extraPEMs {
+		pool.AppendCertsFromPEM(pem)
+	}
+
+	ts.swap(pool)
+	return nil
+}
+
+// swap installs pool as the current pool and updates the metrics. The
+// caller must hold ts.mu.
+func (ts *TrustStore) swap(pool *x509.CertPool) {
+	ts.pool.Store(pool)
+	ts.certCount.Set(float64(len(pool.Subjects())))
+	ts.lastReload.Set(float64(time.Now().Unix()))
+}
+
+// watch starts an fsnotify watch on the bundle path and reloads the
+// pool on every relevant event. When bundlePath is a single file (the
+// common case), the file itself is not watched directly: certificate
+// renewal tools typically replace it via an atomic rename, which
+// leaves a direct inotify watch pointed at the old, now-deleted inode
+// and silently stops delivering further events. Watching the parent
+// directory and filtering by filename survives that replacement.
+func (ts *TrustStore) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	watchPath := ts.bundlePath
+	isFile := false
+	if info, statErr := os.Stat(ts.bundlePath); statErr == nil && !info.IsDir() {
+		isFile = true
+		watchPath = filepath.Dir(ts.bundlePath)
+	}
+
+	if err := watcher.Add(watchPath); err != nil {
+		watcher.Close()
+		return err
+	}
+	ts.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if isFile && filepath.Clean(event.Name) != filepath.Clean(ts.bundlePath) {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				if err := ts.Reload(); err != nil {
+					log.Error.Println("Trust store reload failed:", err)
+				} else {
+					log.Info.Println("Trust store reloaded from", ts.bundlePath)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error.Println("Trust store watcher error:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (ts *TrustStore) Describe(ch chan<- *prometheus.Desc) {
+	ts.certCount.Describe(ch)
+	ts.lastReload.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (ts *TrustStore) Collect(ch chan<- prometheus.Metric) {
+	ts.certCount.Collect(ch)
+	ts.lastReload.Collect(ch)
+}
+
+// addBundlePath reads path (a single file, or every *.pem/*.crt file
+// in a directory) and appends the certificates it contains to pool.
+func addBundlePath(pool *x509.CertPool, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return appendPEMFile(pool, path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".pem", ".crt":
+		default:
+			continue
+		}
+		if err := appendPEMFile(pool, filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendPEMFile(pool *x509.CertPool, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("fbx: no valid certificate found in %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package fbx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// selfSignedTestCert is an arbitrary, valid self-signed certificate
+// used only to exercise bundle loading; it does not need to chain to
+// anything.
+const selfSignedTestCert = `-----BEGIN CERTIFICATE-----
+MIIBVDCB+6ADAgECAgEBMAoGCCqGSM49BAMCMBIxEDAOBgNVBAoTB1Rlc3QgQ0Ew
+HhcNMjYwNzI3MDA0MjEyWhcNMzYwNzI3MDA0MjEyWjASMRAwDgYDVQQKEwdUZXN0
+IENBMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEFJwLtqjPjuFuDz29RQeSWGAk
+xK+JIxSveB/pCmzTPUqPQSuBSly1FmGvj3+gAe0EK8cycKUBCQP9mLbBozxsC6NC
+MEAwDgYDVR0PAQH/BAQDAgKEMA8GA1UdEwEB/wQFMAMBAf8wHQYDVR0OBBYEFK+f
+RO59WX8haAtMxBM7odIVAMY5MAoGCCqGSM49BAMCA0gAMEUCIBiwZRWHmgSPbhL7
+TnkMesdt0po7n4MHZsHjdBV6mu8qAiEAkD+DxHYq50HV+ywQOQVB4e7+ZKj6sp/a
++NxxEwxMZ9s=
+-----END CERTIFICATE-----
+`
+
+func TestTrustStoreReloadWithBundleFile(t *testing.T) {
+	dir := t.TempDir()
+	bundlePath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(bundlePath, []byte(selfSignedTestCert), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	ts, err := NewTrustStore(bundlePath, false)
+	if err != nil {
+		t.Fatalf("NewTrustStore() error = %v", err)
+	}
+
+	if got := len(ts.Pool().Subjects()); got == 0 {
+		t.Errorf("Pool().Subjects() is empty, want at least the embedded defaults")
+	}
+}
+
+func TestTrustStoreReloadMissingBundlePath(t *testing.T) {
+	_, err := NewTrustStore(filepath.Join(t.TempDir(), "does-not-exist.pem"), false)
+	if err == nil {
+		t.Error("NewTrustStore() error = nil, want an error for a missing bundle path")
+	}
+}
+
+func TestTrustStoreAddSurvivesReload(t *testing.T) {
+	ts, err := NewTrustStore("", false)
+	if err != nil {
+		t.Fatalf("NewTrustStore() error = %v", err)
+	}
+
+	if err := ts.Add([]byte(selfSignedTestCert)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	before := len(ts.Pool().Subjects())
+
+	if err := ts.Reload(); err != nil {
+		t.Fatalf("second Reload() error = %v", err)
+	}
+	after := len(ts.Pool().Subjects())
+
+	if after < before {
+		t.Errorf("after Reload(), Subjects() = %d, want at least %d (cert added via Add should survive)", after, before)
+	}
+}
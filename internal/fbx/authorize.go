@@ -1,6 +1,12 @@
 package fbx
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vintzvintz/fbx-exporter/internal/log"
+)
 
 // freeboxAuthorize is a fixed structure for authorization request
 // https://dev.freebox.fr/sdk/os/login/#request-authorization
@@ -36,3 +42,64 @@ var (
 func getFreeboxAuthorize() *freeboxAuthorize {
 	return &authorize
 }
+
+// authorizeResponse is returned by POST /api/vX/login/authorize/.
+// https://dev.freebox.fr/sdk/os/login/#requesting-authorization
+type authorizeResponse struct {
+	AppToken string `json:"app_token"`
+	TrackID  int    `json:"track_id"`
+}
+
+// authorizationStatus is returned by GET /api/vX/login/authorize/{track_id}/.
+// Status stays "pending" until the user accepts or declines the request
+// on the Freebox's front panel.
+type authorizationStatus struct {
+	Status string `json:"status"` // "pending", "granted", "denied", "timeout"
+}
+
+// RequestAuthorization starts the pairing flow: it POSTs the static
+// app descriptor and gets back an app_token (usable once granted) and
+// a track_id to poll for the user's decision on the front panel.
+func RequestAuthorization(client FreeboxHttpClient, apiVersion *FreeboxAPIVersion, queryVersion int) (appToken string, trackID int, err error) {
+	authorizeURL, err := apiVersion.GetURL(queryVersion, "login/authorize/")
+	if err != nil {
+		return "", 0, err
+	}
+
+	var res authorizeResponse
+	if err := client.Post(authorizeURL, getFreeboxAuthorize(), &res); err != nil {
+		return "", 0, err
+	}
+	return res.AppToken, res.TrackID, nil
+}
+
+// WaitForAuthorization polls GET /api/vX/login/authorize/{track_id}/
+// until the user grants or denies the request on the Freebox's front
+// panel, or until timeout has elapsed, polling every pollInterval.
+func WaitForAuthorization(client FreeboxHttpClient, apiVersion *FreeboxAPIVersion, queryVersion int, trackID int, pollInterval, timeout time.Duration) error {
+	statusURL, err := apiVersion.GetURL(queryVersion, fmt.Sprintf("login/authorize/%d/", trackID))
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var status authorizationStatus
+		if err := client.Get(statusURL, &status); err != nil {
+			return err
+		}
+
+		switch status.Status {
+		case "granted":
+			return nil
+		case "denied", "timeout":
+			return fmt.Errorf("fbx: authorization request %s", status.Status)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("fbx: timed out waiting for authorization on the Freebox front panel")
+		}
+		log.Info.Println("Waiting for authorization on the Freebox front panel, track_id:", trackID)
+		time.Sleep(pollInterval)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
@@ -17,53 +17,38 @@ type sessionInfo struct {
 	challenge    string
 }
 
-type retryConfig struct {
-	minDelay      time.Duration
-	maxDelay      time.Duration
-	currentDelay  time.Duration
-	failureCount  int
-	lastFailure   time.Time
-}
-
-func newRetryConfig() *retryConfig {
-	minDelay := 5 * time.Second
-	if env := os.Getenv("FBX_RETRY_MIN_DELAY"); env != "" {
-		if d, err := time.ParseDuration(env); err == nil {
-			minDelay = d
-		}
-	}
-
-	maxDelay := 1 * time.Minute
-	if env := os.Getenv("FBX_RETRY_MAX_DELAY"); env != "" {
-		if d, err := time.ParseDuration(env); err == nil {
-			maxDelay = d
-		}
-	}
-
-	return &retryConfig{
-		minDelay:     minDelay,
-		maxDelay:     maxDelay,
-		currentDelay: minDelay,
-	}
-}
-
 // FreeboxSession represents all the variables used in a session
 type FreeboxSession struct {
 	client             FreeboxHttpClient
+	apiVersion         *FreeboxAPIVersion
+	queryVersion       int
 	getSessionTokenURL string
 	getChallengeURL    string
 
-	appToken string
+	tokenStore TokenStore
+	appToken   string
+	createdAt  time.Time
 
 	sessionTokenLastUpdate time.Time
 	sessionTokenLock       sync.Mutex
 	sessionInfo            *sessionInfo
 	oldSessionInfo         *sessionInfo // avoid deleting the sessionInfo too quickly
+	permissions            map[string]bool
 
-	retryConfig *retryConfig
+	retryConfig *RetryConfig
+	reauth      reauthorizeState
 }
 
-func NewFreeboxSession(appToken string, client FreeboxHttpClient, apiVersion *FreeboxAPIVersion, queryVersion int) (FreeboxHttpClient, error) {
+// NewFreeboxSession loads the app token from tokenStore and opens a
+// session with it. If the app token is later revoked, do transparently
+// starts a new authorization request and persists the resulting token
+// back to tokenStore.
+func NewFreeboxSession(tokenStore TokenStore, client FreeboxHttpClient, apiVersion *FreeboxAPIVersion, queryVersion int) (FreeboxHttpClient, error) {
+	appToken, err := tokenStore.Load()
+	if err != nil {
+		return nil, err
+	}
+
 	getChallengeURL, err := apiVersion.GetURL(queryVersion, "login/")
 	if err != nil {
 		return nil, err
@@ -76,12 +61,16 @@ func NewFreeboxSession(appToken string, client FreeboxHttpClient, apiVersion *Fr
 
 	result := &FreeboxSession{
 		client:             client,
+		apiVersion:         apiVersion,
+		queryVersion:       queryVersion,
 		getSessionTokenURL: getSessionTokenURL,
 		getChallengeURL:    getChallengeURL,
 
-		appToken: appToken,
+		tokenStore: tokenStore,
+		appToken:   appToken,
+		createdAt:  time.Now(),
 
-		retryConfig: newRetryConfig(),
+		retryConfig: NewRetryConfig(),
 	}
 	if err := result.refresh(); err != nil {
 		return nil, err
@@ -89,6 +78,13 @@ func NewFreeboxSession(appToken string, client FreeboxHttpClient, apiVersion *Fr
 	return result, nil
 }
 
+// WebSocketURL returns the ws(s):// endpoint to dial for push
+// notifications, derived from the same API root used for GET/POST
+// polling.
+func (f *FreeboxSession) WebSocketURL() (string, error) {
+	return wsURLFromAPIVersion(f.apiVersion, f.queryVersion)
+}
+
 func (f *FreeboxSession) Get(url string, out interface{}, callbacks ...FreeboxHttpClientCallback) error {
 	action := func() error {
 		return f.client.Get(url, out, f.addHeader)
@@ -104,61 +100,209 @@ func (f *FreeboxSession) Post(url string, in interface{}, out interface{}, callb
 }
 
 func (f *FreeboxSession) do(action func() error) error {
-	if err := action(); err != nil {
-		switch err {
-		case errAuthRequired, errInvalidToken:
-			// Apply exponential backoff if there were recent failures
-			if f.shouldWaitBeforeRetry() {
-				log.Warning.Printf("Login failure backoff: waiting %v before retry (failure count: %d)",
-					f.retryConfig.currentDelay, f.retryConfig.failureCount)
-				time.Sleep(f.retryConfig.currentDelay)
-			}
-
-			err := f.refresh()
-			if err != nil {
-				f.recordFailure()
-				return err
-			}
-
-			// Reset retry state on successful refresh
-			f.resetRetryState()
-			return action()
-		default:
-			return err
+	err := action()
+	if err == nil {
+		return nil
+	}
+
+	switch err {
+	case errAuthRequired:
+		return f.retryWithRefresh(action)
+	case errInvalidToken:
+		if retryErr := f.retryWithRefresh(action); retryErr == nil {
+			return nil
 		}
+		return f.retryWithReauthorize(action)
+	default:
+		return err
 	}
+}
 
-	return nil
+// retryWithRefresh re-derives a session token from the current app
+// token and retries action, backing off first if recent attempts
+// already failed.
+func (f *FreeboxSession) retryWithRefresh(action func() error) error {
+	if f.retryConfig.ShouldWait() {
+		log.Warning.Printf("Login failure backoff: waiting %v before retry", f.retryConfig.CurrentDelay())
+		time.Sleep(f.retryConfig.CurrentDelay())
+	}
+
+	if err := f.refresh(); err != nil {
+		f.retryConfig.RecordFailure()
+		return err
+	}
+
+	f.retryConfig.Reset()
+	return action()
+}
+
+// retryWithReauthorize is reached when the app token itself is no
+// longer valid (e.g. revoked on the Freebox front panel): it requests
+// a new one, waits for the user to accept it, persists it, and
+// retries action.
+func (f *FreeboxSession) retryWithReauthorize(action func() error) error {
+	if f.tokenStore == nil {
+		return fmt.Errorf("fbx: app token rejected and no token store configured for automatic re-authorization")
+	}
+
+	log.Warning.Println("App token rejected, starting a new authorization request")
+	if err := f.reauthorize(); err != nil {
+		return err
+	}
+	return action()
+}
+
+// reauthorize runs the pairing flow from scratch and installs the
+// resulting app token.
+func (f *FreeboxSession) reauthorize() error {
+	appToken, trackID, err := RequestAuthorization(f.client, f.apiVersion, f.queryVersion)
+	if err != nil {
+		return err
+	}
+	log.Info.Println("New authorization requested, accept it on the Freebox front panel, track_id:", trackID)
+
+	if err := WaitForAuthorization(f.client, f.apiVersion, f.queryVersion, trackID, 2*time.Second, 5*time.Minute); err != nil {
+		return err
+	}
+
+	return f.installNewAppToken(appToken)
+}
+
+// installNewAppToken persists appToken, installs it as the app token
+// in use, and derives a fresh session token from it. f.appToken and
+// f.createdAt are guarded by sessionTokenLock since they can now be
+// written concurrently by an automatic retryWithReauthorize (triggered
+// from a failed scrape) and an admin-triggered RevokeAndReauthorize.
+func (f *FreeboxSession) installNewAppToken(appToken string) error {
+	if err := f.tokenStore.Save(appToken); err != nil {
+		return err
+	}
+
+	f.sessionTokenLock.Lock()
+	f.appToken = appToken
+	f.createdAt = time.Now()
+	// Clear the last update so refresh does not skip deriving a session
+	// token from the new app token.
+	f.sessionTokenLastUpdate = time.Time{}
+	f.sessionTokenLock.Unlock()
+
+	return f.refresh()
 }
 
-func (f *FreeboxSession) shouldWaitBeforeRetry() bool {
-	if f.retryConfig.failureCount == 0 {
-		return false
+// Logout invalidates the current session token on the Freebox. It
+// does not go through do/retryWithRefresh since a failure here isn't
+// meant to trigger a new login.
+func (f *FreeboxSession) Logout() error {
+	logoutURL, err := f.apiVersion.GetURL(f.queryVersion, "login/logout/")
+	if err != nil {
+		return err
 	}
+	return f.client.Post(logoutURL, struct{}{}, nil, f.addHeader)
+}
 
-	// If the last failure was recent, apply backoff
-	return time.Since(f.retryConfig.lastFailure) < f.retryConfig.currentDelay*2
+// reauthorizeState tracks an in-flight admin-triggered
+// RevokeAndReauthorize, so GET /fbx/token can report its progress
+// instead of the caller blocking on it.
+type reauthorizeState struct {
+	mu      sync.Mutex
+	pending bool
+	trackID int
+	err     error
 }
 
-func (f *FreeboxSession) recordFailure() {
-	f.retryConfig.failureCount++
-	f.retryConfig.lastFailure = time.Now()
+// RevokeAndReauthorize logs the current session out, then starts a
+// fresh authorization request, so rotating a compromised token is a
+// single call instead of an SSH-and-restart trip. It returns as soon
+// as the request is accepted by the Freebox, without waiting for the
+// front-panel approval: that can take up to 5 minutes, far longer
+// than an HTTP client or reverse proxy is willing to wait. Call
+// ReauthorizeStatus to poll progress.
+func (f *FreeboxSession) RevokeAndReauthorize() (trackID int, err error) {
+	if f.tokenStore == nil {
+		return 0, fmt.Errorf("fbx: no token store configured for re-authorization")
+	}
 
-	// Exponential backoff: double the delay up to max
-	f.retryConfig.currentDelay *= 2
-	if f.retryConfig.currentDelay > f.retryConfig.maxDelay {
-		f.retryConfig.currentDelay = f.retryConfig.maxDelay
+	if err := f.Logout(); err != nil {
+		log.Warning.Println("Logout before re-authorization failed, continuing:", err)
 	}
 
-	log.Warning.Printf("Login failure recorded (count: %d, next delay: %v)",
-		f.retryConfig.failureCount, f.retryConfig.currentDelay)
+	appToken, trackID, err := RequestAuthorization(f.client, f.apiVersion, f.queryVersion)
+	if err != nil {
+		return 0, err
+	}
+	log.Info.Println("New authorization requested, accept it on the Freebox front panel, track_id:", trackID)
+
+	f.reauth.mu.Lock()
+	f.reauth.pending = true
+	f.reauth.trackID = trackID
+	f.reauth.err = nil
+	f.reauth.mu.Unlock()
+
+	go func() {
+		waitErr := WaitForAuthorization(f.client, f.apiVersion, f.queryVersion, trackID, 2*time.Second, 5*time.Minute)
+		if waitErr == nil {
+			waitErr = f.installNewAppToken(appToken)
+		}
+		if waitErr != nil {
+			log.Warning.Println("Re-authorization failed:", waitErr)
+		} else {
+			log.Info.Println("Re-authorization succeeded, track_id:", trackID)
+		}
+
+		f.reauth.mu.Lock()
+		f.reauth.pending = false
+		f.reauth.err = waitErr
+		f.reauth.mu.Unlock()
+	}()
+
+	return trackID, nil
+}
+
+// ReauthorizeStatus reports whether an admin-triggered
+// RevokeAndReauthorize is still waiting for front-panel approval, the
+// track_id it is waiting on, and the outcome of the last one once it
+// has finished.
+func (f *FreeboxSession) ReauthorizeStatus() (pending bool, trackID int, err error) {
+	f.reauth.mu.Lock()
+	defer f.reauth.mu.Unlock()
+	return f.reauth.pending, f.reauth.trackID, f.reauth.err
 }
 
-func (f *FreeboxSession) resetRetryState() {
-	if f.retryConfig.failureCount > 0 {
-		log.Info.Printf("Login successful, resetting retry state (was %d failures)", f.retryConfig.failureCount)
-		f.retryConfig.failureCount = 0
-		f.retryConfig.currentDelay = f.retryConfig.minDelay
+// TokenInfo summarizes the app token currently in use, for the /fbx/token
+// admin endpoint.
+type TokenInfo struct {
+	AppID       string
+	AppName     string
+	DeviceName  string
+	Permissions map[string]bool
+	CreatedAt   time.Time
+	LastSeen    time.Time
+
+	ReauthorizePending bool
+	ReauthorizeTrackID int
+	ReauthorizeErr     error
+}
+
+// TokenInfo returns a snapshot of the current app token's identity,
+// permissions, usage, and the status of the last RevokeAndReauthorize
+// call, if any.
+func (f *FreeboxSession) TokenInfo() TokenInfo {
+	auth := getFreeboxAuthorize()
+	pending, trackID, reauthErr := f.ReauthorizeStatus()
+
+	f.sessionTokenLock.Lock()
+	defer f.sessionTokenLock.Unlock()
+	return TokenInfo{
+		AppID:       auth.AppID,
+		AppName:     auth.AppName,
+		DeviceName:  auth.DeviceName,
+		Permissions: f.permissions,
+		CreatedAt:   f.createdAt,
+		LastSeen:    f.sessionTokenLastUpdate,
+
+		ReauthorizePending: pending,
+		ReauthorizeTrackID: trackID,
+		ReauthorizeErr:     reauthErr,
 	}
 }
 
@@ -168,6 +312,15 @@ func (f *FreeboxSession) addHeader(req *http.Request) {
 	}
 }
 
+// currentSessionToken returns the session token currently in use, or
+// the empty string if no session has been established yet.
+func (f *FreeboxSession) currentSessionToken() string {
+	if f == nil || f.sessionInfo == nil {
+		return ""
+	}
+	return f.sessionInfo.sessionToken
+}
+
 func (f *FreeboxSession) refresh() error {
 	f.sessionTokenLock.Lock()
 	defer f.sessionTokenLock.Unlock()
@@ -181,7 +334,7 @@ func (f *FreeboxSession) refresh() error {
 	if err != nil {
 		return err
 	}
-	sessionToken, err := f.getSessionToken(challenge)
+	sessionToken, permissions, err := f.getSessionToken(challenge)
 	if err != nil {
 		return err
 	}
@@ -191,6 +344,7 @@ func (f *FreeboxSession) refresh() error {
 		challenge:    challenge,
 		sessionToken: sessionToken,
 	}
+	f.permissions = permissions
 	return nil
 }
 
@@ -208,7 +362,7 @@ func (f *FreeboxSession) getChallenge() (string, error) {
 	return resStruct.Challenge, nil
 }
 
-func (f *FreeboxSession) getSessionToken(challenge string) (string, error) {
+func (f *FreeboxSession) getSessionToken(challenge string) (string, map[string]bool, error) {
 	log.Debug.Println("GET SessionToken:", f.getSessionTokenURL)
 	freeboxAuthorize := getFreeboxAuthorize()
 
@@ -224,13 +378,14 @@ func (f *FreeboxSession) getSessionToken(challenge string) (string, error) {
 		Password: password,
 	}
 	resStruct := struct {
-		SessionToken string `json:"session_token"`
+		SessionToken string          `json:"session_token"`
+		Permissions  map[string]bool `json:"permissions"`
 	}{}
 
 	if err := f.client.Post(f.getSessionTokenURL, &reqStruct, &resStruct); err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	log.Debug.Println("SessionToken:", resStruct.SessionToken)
-	return resStruct.SessionToken, nil
+	return resStruct.SessionToken, resStruct.Permissions, nil
 }
@@ -0,0 +1,46 @@
+package fbx
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// NotificationMetrics turns Freebox websocket push notifications into
+// Prometheus counters, one per (source, action) pair, so the collector
+// can expose "something changed" without polling the subsystem on
+// every scrape.
+type NotificationMetrics struct {
+	ws      *FreeboxWebSocket
+	counter *prometheus.CounterVec
+}
+
+// NewNotificationMetrics subscribes ws to sources and counts every
+// notification received, labeled by source and action.
+func NewNotificationMetrics(ws *FreeboxWebSocket, sources ...string) (*NotificationMetrics, error) {
+	if err := ws.Subscribe(sources...); err != nil {
+		return nil, err
+	}
+
+	nm := &NotificationMetrics{
+		ws: ws,
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fbx_notifications_total",
+			Help: "Number of websocket push notifications received, by source and action.",
+		}, []string{"source", "action"}),
+	}
+	go nm.run()
+	return nm, nil
+}
+
+func (nm *NotificationMetrics) run() {
+	for n := range nm.ws.Events() {
+		nm.counter.WithLabelValues(n.Source, n.Action).Inc()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (nm *NotificationMetrics) Describe(ch chan<- *prometheus.Desc) {
+	nm.counter.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (nm *NotificationMetrics) Collect(ch chan<- prometheus.Metric) {
+	nm.counter.Collect(ch)
+}
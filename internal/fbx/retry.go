@@ -0,0 +1,82 @@
+package fbx
+
+import (
+	"os"
+	"time"
+
+	"github.com/vintzvintz/fbx-exporter/internal/log"
+)
+
+// RetryConfig implements exponential backoff with a floor and a
+// ceiling, shared by anything in this package that needs to back off
+// after a failure: session refreshes, websocket reconnects, and
+// scheduled collector jobs.
+type RetryConfig struct {
+	minDelay     time.Duration
+	maxDelay     time.Duration
+	currentDelay time.Duration
+	failureCount int
+	lastFailure  time.Time
+}
+
+// NewRetryConfig builds a RetryConfig with its floor and ceiling taken
+// from FBX_RETRY_MIN_DELAY / FBX_RETRY_MAX_DELAY when set, defaulting
+// to 5s and 1m.
+func NewRetryConfig() *RetryConfig {
+	minDelay := 5 * time.Second
+	if env := os.Getenv("FBX_RETRY_MIN_DELAY"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil {
+			minDelay = d
+		}
+	}
+
+	maxDelay := 1 * time.Minute
+	if env := os.Getenv("FBX_RETRY_MAX_DELAY"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil {
+			maxDelay = d
+		}
+	}
+
+	return &RetryConfig{
+		minDelay:     minDelay,
+		maxDelay:     maxDelay,
+		currentDelay: minDelay,
+	}
+}
+
+// CurrentDelay returns the delay to sleep when ShouldWait reports true.
+func (r *RetryConfig) CurrentDelay() time.Duration {
+	return r.currentDelay
+}
+
+// ShouldWait reports whether a caller should sleep CurrentDelay before
+// retrying, because a recent failure was recorded.
+func (r *RetryConfig) ShouldWait() bool {
+	if r.failureCount == 0 {
+		return false
+	}
+	return time.Since(r.lastFailure) < r.currentDelay*2
+}
+
+// RecordFailure doubles the backoff delay, up to maxDelay.
+func (r *RetryConfig) RecordFailure() {
+	r.failureCount++
+	r.lastFailure = time.Now()
+
+	r.currentDelay *= 2
+	if r.currentDelay > r.maxDelay {
+		r.currentDelay = r.maxDelay
+	}
+
+	log.Warning.Printf("Failure recorded (count: %d, next delay: %v)",
+		r.failureCount, r.currentDelay)
+}
+
+// Reset clears the failure count and restores the floor delay.
+func (r *RetryConfig) Reset() {
+	if r.failureCount > 0 {
+		log.Info.Printf("Recovered, resetting retry state (was %d failures)", r.failureCount)
+		r.failureCount = 0
+		r.currentDelay = r.minDelay
+	}
+}
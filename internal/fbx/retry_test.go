@@ -0,0 +1,37 @@
+package fbx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryConfigBackoff(t *testing.T) {
+	r := &RetryConfig{minDelay: 1 * time.Second, maxDelay: 10 * time.Second, currentDelay: 1 * time.Second}
+
+	if r.ShouldWait() {
+		t.Fatal("ShouldWait() = true before any failure, want false")
+	}
+
+	r.RecordFailure()
+	if got := r.CurrentDelay(); got != 2*time.Second {
+		t.Errorf("after 1st failure, CurrentDelay() = %v, want %v", got, 2*time.Second)
+	}
+	if !r.ShouldWait() {
+		t.Error("ShouldWait() = false right after a failure, want true")
+	}
+
+	r.RecordFailure()
+	r.RecordFailure()
+	r.RecordFailure()
+	if got := r.CurrentDelay(); got != 10*time.Second {
+		t.Errorf("after repeated failures, CurrentDelay() = %v, want the ceiling %v", got, 10*time.Second)
+	}
+
+	r.Reset()
+	if r.ShouldWait() {
+		t.Error("ShouldWait() = true after Reset(), want false")
+	}
+	if got := r.CurrentDelay(); got != r.minDelay {
+		t.Errorf("after Reset(), CurrentDelay() = %v, want the floor %v", got, r.minDelay)
+	}
+}
@@ -0,0 +1,86 @@
+package fbx
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vintzvintz/fbx-exporter/internal/log"
+)
+
+// tokenInfoResponse is the JSON shape returned by GET /fbx/token.
+type tokenInfoResponse struct {
+	AppID       string          `json:"app_id"`
+	AppName     string          `json:"app_name"`
+	DeviceName  string          `json:"device_name"`
+	Permissions map[string]bool `json:"permissions"`
+	CreatedAt   string          `json:"created_at"`
+	LastSeen    string          `json:"last_seen"`
+
+	ReauthorizePending bool   `json:"reauthorize_pending"`
+	ReauthorizeTrackID int    `json:"reauthorize_track_id,omitempty"`
+	ReauthorizeError   string `json:"reauthorize_error,omitempty"`
+}
+
+func toTokenInfoResponse(info TokenInfo) tokenInfoResponse {
+	resp := tokenInfoResponse{
+		AppID:       info.AppID,
+		AppName:     info.AppName,
+		DeviceName:  info.DeviceName,
+		Permissions: info.Permissions,
+		CreatedAt:   info.CreatedAt.Format(rfc3339),
+		LastSeen:    info.LastSeen.Format(rfc3339),
+
+		ReauthorizePending: info.ReauthorizePending,
+		ReauthorizeTrackID: info.ReauthorizeTrackID,
+	}
+	if info.ReauthorizeErr != nil {
+		resp.ReauthorizeError = info.ReauthorizeErr.Error()
+	}
+	return resp
+}
+
+const rfc3339 = "2006-01-02T15:04:05Z07:00"
+
+// TokenAdminHandler serves /fbx/token: GET shows the app token's
+// identity, permissions and usage; DELETE logs the current session out
+// and starts a new authorization request, whose app token replaces the
+// stored one once approved. The previous app token itself is not
+// revoked on the Freebox. The caller is responsible for wrapping the
+// returned handler with the exporter's usual auth middleware.
+func TokenAdminHandler(session *FreeboxSession) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeTokenInfo(w, session.TokenInfo())
+		case http.MethodDelete:
+			trackID, err := session.RevokeAndReauthorize()
+			if err != nil {
+				log.Error.Println("Token revocation/re-authorization failed:", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Re-authorization runs in the background: the front-panel
+			// approval it waits on can take up to 5 minutes, so report
+			// "pending" immediately instead of blocking the request.
+			// Poll GET /fbx/token for the outcome.
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			if err := json.NewEncoder(w).Encode(struct {
+				Status  string `json:"status"`
+				TrackID int    `json:"track_id"`
+			}{Status: "pending", TrackID: trackID}); err != nil {
+				log.Error.Println("Could not encode re-authorization response:", err)
+			}
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeTokenInfo(w http.ResponseWriter, info TokenInfo) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(toTokenInfoResponse(info)); err != nil {
+		log.Error.Println("Could not encode token info response:", err)
+	}
+}
@@ -0,0 +1,41 @@
+package fbx
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestToTokenInfoResponse(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastSeen := time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC)
+
+	resp := toTokenInfoResponse(TokenInfo{
+		AppID:              "com.example.app",
+		AppName:            "freebox-exporter",
+		DeviceName:         "host",
+		Permissions:        map[string]bool{"settings": true},
+		CreatedAt:          createdAt,
+		LastSeen:           lastSeen,
+		ReauthorizePending: true,
+		ReauthorizeTrackID: 42,
+	})
+
+	if resp.AppID != "com.example.app" || resp.AppName != "freebox-exporter" || resp.DeviceName != "host" {
+		t.Errorf("identity fields not carried over: %+v", resp)
+	}
+	if resp.CreatedAt != createdAt.Format(rfc3339) {
+		t.Errorf("CreatedAt = %q, want %q", resp.CreatedAt, createdAt.Format(rfc3339))
+	}
+	if !resp.ReauthorizePending || resp.ReauthorizeTrackID != 42 {
+		t.Errorf("reauthorize fields not carried over: %+v", resp)
+	}
+	if resp.ReauthorizeError != "" {
+		t.Errorf("ReauthorizeError = %q, want empty when TokenInfo.ReauthorizeErr is nil", resp.ReauthorizeError)
+	}
+
+	withErr := toTokenInfoResponse(TokenInfo{ReauthorizeErr: errors.New("denied")})
+	if withErr.ReauthorizeError != "denied" {
+		t.Errorf("ReauthorizeError = %q, want %q", withErr.ReauthorizeError, "denied")
+	}
+}
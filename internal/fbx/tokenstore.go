@@ -0,0 +1,79 @@
+package fbx
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// TokenStore persists the Freebox app token at rest. The file backend
+// keeps the previous on-disk format (a plain token file); the keyring
+// backend avoids leaving the token as world-readable plaintext on
+// systems where an OS keyring is available.
+type TokenStore interface {
+	Load() (string, error)
+	Save(appToken string) error
+}
+
+// FileTokenStore stores the app token as the sole contents of a file,
+// matching the historical api_token_file behaviour.
+type FileTokenStore struct {
+	path string
+}
+
+// NewFileTokenStore builds a TokenStore backed by the file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) Load() (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Save atomically replaces the token file's contents so a reload
+// racing a rewrite never observes a truncated file.
+func (s *FileTokenStore) Save(appToken string) error {
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(appToken), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// keyringService is the service name app tokens are stored under in
+// the OS keyring.
+const keyringService = "fbx-exporter"
+
+// KeyringTokenStore stores the app token in the OS keyring (via
+// zalando/go-keyring) under keyringService/account, so it never
+// touches disk as plaintext.
+type KeyringTokenStore struct {
+	account string
+}
+
+// NewKeyringTokenStore builds a TokenStore backed by the OS keyring,
+// keyed by account (typically the app ID).
+func NewKeyringTokenStore(account string) *KeyringTokenStore {
+	return &KeyringTokenStore{account: account}
+}
+
+func (s *KeyringTokenStore) Load() (string, error) {
+	token, err := keyring.Get(keyringService, s.account)
+	if err != nil {
+		return "", fmt.Errorf("fbx: loading app token from keyring: %w", err)
+	}
+	return token, nil
+}
+
+func (s *KeyringTokenStore) Save(appToken string) error {
+	if err := keyring.Set(keyringService, s.account, appToken); err != nil {
+		return fmt.Errorf("fbx: saving app token to keyring: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package fbx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileTokenStoreSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_token_file")
+	s := NewFileTokenStore(path)
+
+	if err := s.Save("sometoken"); err != nil {
+		t.Fatalf("Save() = %v, want nil", err)
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+	if got != "sometoken" {
+		t.Errorf("Load() = %q, want %q", got, "sometoken")
+	}
+}
+
+func TestFileTokenStoreLoadIgnoresInterruptedSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_token_file")
+	s := NewFileTokenStore(path)
+
+	if err := s.Save("firsttoken"); err != nil {
+		t.Fatalf("Save() = %v, want nil", err)
+	}
+
+	// Simulate Save being interrupted between writing the temp file and
+	// renaming it into place: the temp file alone must not be mistaken
+	// for a committed save, and the previous token must still Load().
+	if err := os.WriteFile(path+".tmp", []byte("interrupted"), 0o600); err != nil {
+		t.Fatalf("WriteFile(tmp) = %v, want nil", err)
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+	if got != "firsttoken" {
+		t.Errorf("Load() after interrupted write = %q, want the previous token %q", got, "firsttoken")
+	}
+}
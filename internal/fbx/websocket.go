@@ -0,0 +1,261 @@
+package fbx
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/vintzvintz/fbx-exporter/fbx"
+	"github.com/vintzvintz/fbx-exporter/internal/log"
+)
+
+// defaultWsReadBufferSize is the size of the gorilla/websocket read buffer.
+// The default of 4 KiB used by the library is far too small for
+// lan_host_l3addr_reachable and downloader_task notifications, which
+// routinely carry the full host or task list and get silently dropped
+// once they exceed the buffer.
+const defaultWsReadBufferSize = 1 << 20 // 1 MiB
+
+// Notification is a single message pushed by the Freebox over
+// /api/vX/ws/event/. Result is left as raw JSON because its shape
+// depends on Source/Action, and callers decode only what they need.
+// https://dev.freebox.fr/sdk/os/#websocket-notifications
+type Notification struct {
+	Source string          `json:"source"`
+	Action string          `json:"action"`
+	Result json.RawMessage `json:"result"`
+}
+
+// registrationRequest lists the notification sources to subscribe to,
+// e.g. "lan_host_l3addr_reachable", "phone_call", "vm_state_changed",
+// "downloader_task".
+type registrationRequest struct {
+	Action string   `json:"action"`
+	Events []string `json:"events"`
+}
+
+// authFrame authenticates the connection once it is established, using
+// the session token from the current FreeboxSession.
+// https://dev.freebox.fr/sdk/os/#websocket-notifications
+type authFrame struct {
+	Action       string `json:"action"`
+	SessionToken string `json:"session_token"`
+}
+
+// FreeboxWebSocket streams push notifications from the Freebox instead
+// of requiring the collector to poll each subsystem on every scrape.
+type FreeboxWebSocket struct {
+	session    *FreeboxSession
+	wsURL      string
+	trustStore *fbx.TrustStore
+	dialer     *websocket.Dialer
+	readSize   int
+
+	events chan Notification
+
+	mu          sync.Mutex
+	conn        *websocket.Conn
+	closed      bool
+	lastSources []string
+
+	retryConfig *RetryConfig
+}
+
+// NewFreeboxWebSocket dials wsURL (the Freebox's /api/vX/ws/event/
+// endpoint) and starts streaming notifications into Events(). session
+// supplies the session token sent in the auth frame on every dial,
+// including reconnects; the websocket does not refresh the session
+// itself when the Freebox refuses that token. trustStore is used to
+// validate the server's certificate on every dial, including
+// reconnects, so a later -caBundle reload is picked up without
+// restarting the process; it may be nil, in which case the embedded
+// default trust is used.
+func NewFreeboxWebSocket(session *FreeboxSession, wsURL string, trustStore *fbx.TrustStore) (*FreeboxWebSocket, error) {
+	readSize := defaultWsReadBufferSize
+	if env := os.Getenv("FBX_WS_READ_BUFFER_SIZE"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			readSize = n
+		}
+	}
+
+	f := &FreeboxWebSocket{
+		session:     session,
+		wsURL:       wsURL,
+		trustStore:  trustStore,
+		readSize:    readSize,
+		events:      make(chan Notification, 64),
+		retryConfig: NewRetryConfig(),
+	}
+	f.dialer = &websocket.Dialer{
+		TLSClientConfig: f.tlsConfig(),
+		ReadBufferSize:  readSize,
+	}
+
+	if err := f.connect(); err != nil {
+		return nil, err
+	}
+	go f.readLoop()
+	return f, nil
+}
+
+// tlsConfig returns the TLS config to dial with, preferring the trust
+// store so CA bundle reloads take effect on the next reconnect.
+func (f *FreeboxWebSocket) tlsConfig() *tls.Config {
+	if f.trustStore != nil {
+		return f.trustStore.TLSConfig()
+	}
+	return fbx.NewDefaultTLSConfig()
+}
+
+// Events returns the channel notifications are delivered on. It is
+// closed once Close has been called and the read loop has exited.
+func (f *FreeboxWebSocket) Events() <-chan Notification {
+	return f.events
+}
+
+// Subscribe registers for the given notification sources on the
+// current connection, e.g. Subscribe("lan_host_l3addr_reachable",
+// "phone_call", "vm_state_changed", "downloader_task").
+func (f *FreeboxWebSocket) Subscribe(sources ...string) error {
+	f.mu.Lock()
+	f.lastSources = sources
+	conn := f.conn
+	f.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("fbx: websocket not connected")
+	}
+	return conn.WriteJSON(registrationRequest{
+		Action: "register",
+		Events: sources,
+	})
+}
+
+// resubscribe replays the last Subscribe call on the current
+// connection. It is a no-op if Subscribe was never called, and is
+// meant to be called after every reconnect: the Freebox does not
+// remember registrations across connections, so without this a
+// transient network blip would silently stop all notification
+// delivery until the process is restarted.
+func (f *FreeboxWebSocket) resubscribe() error {
+	f.mu.Lock()
+	sources := f.lastSources
+	conn := f.conn
+	f.mu.Unlock()
+	if len(sources) == 0 || conn == nil {
+		return nil
+	}
+	return conn.WriteJSON(registrationRequest{
+		Action: "register",
+		Events: sources,
+	})
+}
+
+// Close stops the read loop and releases the underlying connection.
+func (f *FreeboxWebSocket) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
+
+func (f *FreeboxWebSocket) connect() error {
+	f.mu.Lock()
+	f.dialer.TLSClientConfig = f.tlsConfig()
+	f.mu.Unlock()
+
+	conn, _, err := f.dialer.Dial(f.wsURL, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.WriteJSON(authFrame{
+		Action:       "auth",
+		SessionToken: f.session.currentSessionToken(),
+	}); err != nil {
+		conn.Close()
+		return fmt.Errorf("fbx: could not send websocket auth frame: %w", err)
+	}
+
+	f.mu.Lock()
+	f.conn = conn
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *FreeboxWebSocket) readLoop() {
+	defer close(f.events)
+
+	for {
+		f.mu.Lock()
+		closed := f.closed
+		conn := f.conn
+		f.mu.Unlock()
+		if closed {
+			return
+		}
+
+		var n Notification
+		if err := conn.ReadJSON(&n); err != nil {
+			log.Warning.Println("Websocket read error:", err)
+			if f.shouldStopReconnecting() {
+				return
+			}
+			f.reconnect()
+			continue
+		}
+		f.retryConfig.Reset()
+		f.events <- n
+	}
+}
+
+func (f *FreeboxWebSocket) shouldStopReconnecting() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *FreeboxWebSocket) reconnect() {
+	f.retryConfig.RecordFailure()
+	log.Warning.Printf("Websocket reconnect backoff: waiting %v", f.retryConfig.CurrentDelay())
+	time.Sleep(f.retryConfig.CurrentDelay())
+
+	if err := f.connect(); err != nil {
+		log.Warning.Println("Websocket reconnect failed:", err)
+		return
+	}
+	if err := f.resubscribe(); err != nil {
+		log.Warning.Println("Websocket resubscribe after reconnect failed:", err)
+	}
+}
+
+// wsURLFromAPIVersion builds the ws:// (or wss:// for TLS API roots)
+// endpoint from the same base URL used for GET/POST polling.
+func wsURLFromAPIVersion(apiVersion *FreeboxAPIVersion, queryVersion int) (string, error) {
+	base, err := apiVersion.GetURL(queryVersion, "ws/event/")
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,33 @@
+package fbx
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAuthFrameJSON(t *testing.T) {
+	data, err := json.Marshal(authFrame{Action: "auth", SessionToken: "tok"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"action":"auth","session_token":"tok"}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestRegistrationRequestJSON(t *testing.T) {
+	data, err := json.Marshal(registrationRequest{
+		Action: "register",
+		Events: []string{"phone_call", "vm_state_changed"},
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"action":"register","events":["phone_call","vm_state_changed"]}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
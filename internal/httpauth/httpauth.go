@@ -0,0 +1,183 @@
+// Package httpauth protects the exporter's HTTP endpoints with
+// server-side TLS (optionally requiring a client certificate) and/or
+// OIDC bearer tokens. Either mechanism is optional on its own; when
+// both are configured, a request must satisfy both.
+package httpauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/vintzvintz/fbx-exporter/log"
+)
+
+// AuthFailures counts rejected requests by reason, e.g.
+// "missing_token", "invalid_signature", "expired", "bad_audience".
+var AuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "fbx_http_auth_failures_total",
+	Help: "Number of HTTP requests rejected by the auth middleware, by reason.",
+}, []string{"reason"})
+
+// NewServerTLSConfig builds the *tls.Config used to serve HTTPS. When
+// clientCA is non-empty, client certificates are required and verified
+// against it (mTLS); otherwise plain server-side TLS is used.
+func NewServerTLSConfig(clientCA string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if clientCA == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, fmt.Errorf("httpauth: reading client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("httpauth: no valid certificate found in %s", clientCA)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// oidcDiscovery is the subset of the issuer's
+// /.well-known/openid-configuration document that is needed to find
+// the JWKS endpoint.
+type oidcDiscovery struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// OIDCValidator verifies bearer tokens against an issuer's published
+// JWKS, refreshing the key set on a fixed interval so key rotation on
+// the issuer's side doesn't require restarting the exporter.
+type OIDCValidator struct {
+	issuer   string
+	audience string
+	jwksURI  string
+
+	keyfunc atomic.Pointer[jwt.Keyfunc]
+}
+
+// NewOIDCValidator fetches issuer's discovery document and initial
+// JWKS, then refreshes the JWKS every refresh interval.
+func NewOIDCValidator(issuer, audience string, refresh time.Duration) (*OIDCValidator, error) {
+	v := &OIDCValidator{
+		issuer:   issuer,
+		audience: audience,
+	}
+
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	var discovery oidcDiscovery
+	if err := getJSON(discoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("httpauth: fetching OIDC discovery document: %w", err)
+	}
+	v.jwksURI = discovery.JWKSURI
+
+	if err := v.refreshJWKS(); err != nil {
+		return nil, err
+	}
+
+	go v.refreshLoop(refresh)
+	return v, nil
+}
+
+func (v *OIDCValidator) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := v.refreshJWKS(); err != nil {
+			log.Warning.Println("OIDC JWKS refresh failed:", err)
+		}
+	}
+}
+
+func (v *OIDCValidator) refreshJWKS() error {
+	keyfunc, err := newKeyfuncFromJWKS(v.jwksURI)
+	if err != nil {
+		return err
+	}
+	v.keyfunc.Store(&keyfunc)
+	return nil
+}
+
+// Middleware wraps next so that requests must carry a bearer token
+// signed by the configured issuer, for the configured audience, not
+// expired and not used before its nbf claim.
+func (v *OIDCValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authz := r.Header.Get("Authorization")
+		tokenString, ok := strings.CutPrefix(authz, "Bearer ")
+		if !ok || tokenString == "" {
+			reject(w, "missing_token")
+			return
+		}
+
+		keyfunc := v.keyfunc.Load()
+		if keyfunc == nil {
+			reject(w, "jwks_unavailable")
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, *keyfunc,
+			jwt.WithIssuer(v.issuer),
+			jwt.WithAudience(v.audience),
+			jwt.WithExpirationRequired(),
+		)
+		if err != nil || !token.Valid {
+			reject(w, classifyJWTError(err))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func reject(w http.ResponseWriter, reason string) {
+	AuthFailures.WithLabelValues(reason).Inc()
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+func classifyJWTError(err error) string {
+	switch {
+	case err == nil:
+		return "invalid_token"
+	case strings.Contains(err.Error(), "token is expired"):
+		return "expired"
+	case strings.Contains(err.Error(), "audience"):
+		return "bad_audience"
+	case strings.Contains(err.Error(), "issuer"):
+		return "bad_issuer"
+	default:
+		return "invalid_signature"
+	}
+}
+
+func getJSON(url string, out interface{}) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("httpauth: GET %s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
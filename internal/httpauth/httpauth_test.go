@@ -0,0 +1,28 @@
+package httpauth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyJWTError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil error", nil, "invalid_token"},
+		{"expired", errors.New("token is expired by 1h0m0s"), "expired"},
+		{"bad audience", errors.New("token has invalid audience"), "bad_audience"},
+		{"bad issuer", errors.New("token has invalid issuer"), "bad_issuer"},
+		{"bad signature", errors.New("signature is invalid"), "invalid_signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyJWTError(tt.err); got != tt.want {
+				t.Errorf("classifyJWTError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package httpauth
+
+import (
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newKeyfuncFromJWKS fetches the JWKS at jwksURI once and returns a
+// jwt.Keyfunc that looks up the signing key by the token's "kid".
+func newKeyfuncFromJWKS(jwksURI string) (jwt.Keyfunc, error) {
+	jwks, err := keyfunc.Get(jwksURI, keyfunc.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return jwks.Keyfunc, nil
+}
@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JobConfig is one entry of the schedule file, e.g.
+//
+//	jobs:
+//	  - name: lan_hosts
+//	    schedule: "*/5 * * * *"
+//	  - name: system
+//	    schedule: "*/30 * * * * *"
+//	  - name: vm
+//	    schedule: "@every 2m"
+type JobConfig struct {
+	Name     string `yaml:"name"`
+	Schedule string `yaml:"schedule"`
+}
+
+// Config is the top-level shape of the -schedule YAML file.
+type Config struct {
+	Jobs []JobConfig `yaml:"jobs"`
+}
+
+var envNameSanitizer = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// LoadConfig reads a Config from path, then lets
+// FBX_SCHEDULE_<JOB_NAME> override each job's schedule expression
+// (job name uppercased, non-alphanumeric runs replaced with "_").
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	for i, job := range cfg.Jobs {
+		envName := "FBX_SCHEDULE_" + envNameSanitizer.ReplaceAllString(strings.ToUpper(job.Name), "_")
+		if override := os.Getenv(envName); override != "" {
+			cfg.Jobs[i].Schedule = override
+		}
+	}
+	return &cfg, nil
+}
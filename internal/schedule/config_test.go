@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schedule.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+jobs:
+  - name: lan_hosts
+    schedule: "*/5 * * * *"
+  - name: system
+    schedule: "*/30 * * * * *"
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if len(cfg.Jobs) != 2 {
+		t.Fatalf("len(cfg.Jobs) = %d, want 2", len(cfg.Jobs))
+	}
+	if cfg.Jobs[0].Name != "lan_hosts" || cfg.Jobs[0].Schedule != "*/5 * * * *" {
+		t.Errorf("cfg.Jobs[0] = %+v, want name=lan_hosts schedule=\"*/5 * * * *\"", cfg.Jobs[0])
+	}
+	if cfg.Jobs[1].Name != "system" || cfg.Jobs[1].Schedule != "*/30 * * * * *" {
+		t.Errorf("cfg.Jobs[1] = %+v, want name=system schedule=\"*/30 * * * * *\"", cfg.Jobs[1])
+	}
+
+	if _, err := cronParser.Parse(cfg.Jobs[1].Schedule); err != nil {
+		t.Errorf("cronParser.Parse(%q) error = %v, want the documented 6-field example to parse", cfg.Jobs[1].Schedule, err)
+	}
+}
+
+func TestLoadConfigEnvOverride(t *testing.T) {
+	path := writeConfig(t, `
+jobs:
+  - name: lan_hosts
+    schedule: "*/5 * * * *"
+`)
+
+	t.Setenv("FBX_SCHEDULE_LAN_HOSTS", "@every 1m")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if got := cfg.Jobs[0].Schedule; got != "@every 1m" {
+		t.Errorf("cfg.Jobs[0].Schedule = %q, want the env override %q", got, "@every 1m")
+	}
+}
@@ -0,0 +1,203 @@
+// Package schedule runs each collector on its own cron-style cadence
+// instead of on every Prometheus scrape, and caches the last result so
+// Collect only ever reads memory.
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+
+	"github.com/vintzvintz/fbx-exporter/internal/fbx"
+	"github.com/vintzvintz/fbx-exporter/internal/log"
+)
+
+// CollectFunc refreshes one collector's data. Its return value is
+// cached until the next run.
+type CollectFunc func() (interface{}, error)
+
+// cachedResult is the last outcome of a job's CollectFunc.
+type cachedResult struct {
+	mu      sync.RWMutex
+	value   interface{}
+	err     error
+	updated time.Time
+}
+
+func (c *cachedResult) set(value interface{}, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value, c.err = value, err
+	c.updated = time.Now()
+}
+
+func (c *cachedResult) get() (interface{}, error, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.value, c.err, c.updated
+}
+
+// job ties a CollectFunc to its cron schedule, retry state, and cache.
+type job struct {
+	name    string
+	cron    cron.Schedule
+	collect CollectFunc
+	retry   *fbx.RetryConfig
+	cached  cachedResult
+}
+
+// Scheduler runs a set of named jobs on independent cadences and
+// exposes fbx_scrape_last_success_timestamp_seconds and
+// fbx_scrape_duration_seconds per job name.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	stop chan struct{}
+
+	lastSuccess *prometheus.GaugeVec
+	duration    *prometheus.HistogramVec
+}
+
+// NewScheduler builds an empty Scheduler. Call Register for each
+// collector, then Start.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs: make(map[string]*job),
+		stop: make(chan struct{}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fbx_scrape_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful run of a scheduled collector.",
+		}, []string{"collector"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "fbx_scrape_duration_seconds",
+			Help: "Duration of a scheduled collector run, successful or not.",
+		}, []string{"collector"}),
+	}
+}
+
+// cronParser accepts the standard 5-field syntax, an optional leading
+// seconds field (6 fields total, e.g. "*/30 * * * * *"), and
+// descriptors like "@every 30s".
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Register adds a job named name, refreshed on the given cron
+// expression (5-field syntax, an optional leading seconds field, or
+// "@every <duration>"). collect is invoked on every tick until Stop is
+// called.
+func (s *Scheduler) Register(name, schedule string, collect CollectFunc) error {
+	sched, err := cronParser.Parse(schedule)
+	if err != nil {
+		return fmt.Errorf("schedule: job %q: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = &job{
+		name:    name,
+		cron:    sched,
+		collect: collect,
+		retry:   fbx.NewRetryConfig(),
+	}
+	return nil
+}
+
+// Start launches one goroutine per registered job. It returns
+// immediately; jobs run until Stop is called.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	for _, j := range jobs {
+		go s.run(j)
+	}
+}
+
+// Stop signals every job goroutine to exit: a job waiting for its next
+// tick exits at once, a job that is running exits when its run ends.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *Scheduler) run(j *job) {
+	for {
+		next := j.cron.Next(time.Now())
+		wait := time.Until(next) + jitter(next.Sub(time.Now()))
+
+		select {
+		case <-time.After(wait):
+		case <-s.stop:
+			return
+		}
+
+		s.execute(j)
+	}
+}
+
+// jitter spreads job runs over up to 10% of the interval until the
+// next tick, so jobs sharing a cadence (e.g. "@every 30s") don't all
+// hit the Freebox API at once.
+func jitter(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(interval) / 10))
+}
+
+func (s *Scheduler) execute(j *job) {
+	if j.retry.ShouldWait() {
+		log.Warning.Printf("Scheduled job %q backoff: waiting %v before retry", j.name, j.retry.CurrentDelay())
+		time.Sleep(j.retry.CurrentDelay())
+	}
+
+	start := time.Now()
+	value, err := j.collect()
+	s.duration.WithLabelValues(j.name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		j.retry.RecordFailure()
+		log.Warning.Printf("Scheduled job %q failed: %v", j.name, err)
+		j.cached.set(nil, err)
+		return
+	}
+
+	j.retry.Reset()
+	j.cached.set(value, nil)
+	s.lastSuccess.WithLabelValues(j.name).Set(float64(time.Now().Unix()))
+}
+
+// Get returns the cached result of the named job's last run. The
+// collector's Collect callback should call this instead of invoking
+// the job directly.
+func (s *Scheduler) Get(name string) (interface{}, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("schedule: unknown job %q", name)
+	}
+
+	value, err, _ := j.cached.get()
+	return value, err
+}
+
+// Describe implements prometheus.Collector.
+func (s *Scheduler) Describe(ch chan<- *prometheus.Desc) {
+	s.lastSuccess.Describe(ch)
+	s.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *Scheduler) Collect(ch chan<- prometheus.Metric) {
+	s.lastSuccess.Collect(ch)
+	s.duration.Collect(ch)
+}
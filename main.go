@@ -5,15 +5,30 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/vintzvintz/fbx-exporter/fbx"
+	internalfbx "github.com/vintzvintz/fbx-exporter/internal/fbx"
+	"github.com/vintzvintz/fbx-exporter/internal/httpauth"
+	"github.com/vintzvintz/fbx-exporter/internal/schedule"
 	"github.com/vintzvintz/fbx-exporter/log"
 )
 
+// notificationSources lists the Freebox websocket event sources the
+// exporter turns into fbx_notifications_total counters.
+var notificationSources = []string{
+	"lan_host_l3addr_reachable",
+	"phone_call",
+	"vm_state_changed",
+	"downloader_task",
+}
+
 func usage() {
 	fmt.Fprintf(flag.CommandLine.Output(),
 		"Usage: %s [options] <api_token_file>\n"+
@@ -33,6 +48,16 @@ func main() {
 	apiVersionPtr := flag.Int("apiVersion", 0, "Force the API version (by default use the latest one)")
 	listenPtr := flag.String("listen", ":9091", "listen to address")
 	goMetricsPtr := flag.Bool("goMetrics", false, "enable Go runtime metrics export")
+	caBundlePtr := flag.String("caBundle", os.Getenv("FBX_CA_BUNDLE"), "path to a CA certificate or directory of certificates to trust, in addition to the embedded Freebox and Let's Encrypt roots (env FBX_CA_BUNDLE)")
+	systemCAPoolPtr := flag.Bool("systemCAPool", false, "also trust the system's root CA store")
+	tlsCertPtr := flag.String("tlsCert", "", "serve /metrics over HTTPS using this certificate file")
+	tlsKeyPtr := flag.String("tlsKey", "", "private key matching -tlsCert")
+	clientCAPtr := flag.String("clientCA", "", "require and verify client certificates against this CA file (enables mTLS, requires -tlsCert/-tlsKey)")
+	oidcIssuerPtr := flag.String("oidcIssuer", "", "require a valid OIDC bearer token from this issuer on every request")
+	oidcAudiencePtr := flag.String("oidcAudience", "", "expected \"aud\" claim of the OIDC bearer token")
+	oidcJWKSRefreshPtr := flag.Duration("oidcJWKSRefresh", 1*time.Hour, "how often to refresh the issuer's JWKS")
+	schedulePtr := flag.String("schedule", "", "path to a YAML file giving each collector its own cron schedule, instead of refreshing every collector on every scrape")
+	tokenKeyringPtr := flag.Bool("tokenKeyring", false, "store the app token in the OS keyring instead of the api_token_file")
 	flag.Parse()
 
 	args := flag.Args()
@@ -50,24 +75,118 @@ func main() {
 	} else {
 		log.Init()
 	}
+	if *clientCAPtr != "" && (*tlsCertPtr == "" || *tlsKeyPtr == "") {
+		log.Error.Fatalln("-clientCA requires -tlsCert/-tlsKey to also be set, otherwise /metrics would be served over plain, unauthenticated HTTP")
+	}
 	discovery := fbx.FreeboxDiscoveryMDNS
 	if *httpDiscoveryPtr {
 		discovery = fbx.FreeboxDiscoveryHTTP
 	}
 
-	collector := NewCollector(args[0], discovery, *apiVersionPtr, *hostDetailsPtr, *debugPtr)
+	trustStore, err := fbx.NewTrustStore(*caBundlePtr, *systemCAPoolPtr)
+	if err != nil {
+		log.Error.Fatalln("Could not build the trust store:", err)
+	}
+
+	var tokenStore internalfbx.TokenStore
+	if *tokenKeyringPtr {
+		tokenStore = internalfbx.NewKeyringTokenStore(args[0])
+	} else {
+		tokenStore = internalfbx.NewFileTokenStore(args[0])
+	}
+
+	var scheduler *schedule.Scheduler
+	var scheduleConfig *schedule.Config
+	if *schedulePtr != "" {
+		cfg, err := schedule.LoadConfig(*schedulePtr)
+		if err != nil {
+			log.Error.Fatalln("Could not load the schedule file:", err)
+		}
+		scheduleConfig = cfg
+		scheduler = schedule.NewScheduler()
+	}
+
+	collector := NewCollector(tokenStore, discovery, *apiVersionPtr, *hostDetailsPtr, *debugPtr, trustStore, scheduler, scheduleConfig)
 	defer collector.Close()
 
+	if scheduler != nil {
+		for _, jobCfg := range scheduleConfig.Jobs {
+			name := jobCfg.Name
+			if err := scheduler.Register(name, jobCfg.Schedule, func() (interface{}, error) {
+				return collector.CollectNamed(name)
+			}); err != nil {
+				log.Error.Fatalln("Could not register scheduled job:", err)
+			}
+		}
+		scheduler.Start()
+		defer scheduler.Stop()
+	}
+
+	// Invalidate the session token on a clean shutdown instead of
+	// leaving it to expire on the Freebox's side.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigCh
+		if err := collector.Logout(); err != nil {
+			log.Warning.Println("Logout on shutdown failed:", err)
+		}
+		os.Exit(0)
+	}()
+
 	// Create custom registry to avoid default Go metrics
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(collector)
+	registry.MustRegister(trustStore)
+	if scheduler != nil {
+		registry.MustRegister(scheduler)
+	}
 
 	if *goMetricsPtr {
 		registry.MustRegister(collectors.NewGoCollector())
 		registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	}
 
-	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	registry.MustRegister(httpauth.AuthFailures)
+
+	if wsURL, err := collector.Session().WebSocketURL(); err != nil {
+		log.Warning.Println("Could not build the websocket URL, push notifications disabled:", err)
+	} else if ws, err := internalfbx.NewFreeboxWebSocket(collector.Session(), wsURL, trustStore); err != nil {
+		log.Warning.Println("Could not connect the notification websocket, push notifications disabled:", err)
+	} else {
+		defer ws.Close()
+		notifMetrics, err := internalfbx.NewNotificationMetrics(ws, notificationSources...)
+		if err != nil {
+			log.Warning.Println("Could not subscribe to notifications:", err)
+		} else {
+			registry.MustRegister(notifMetrics)
+		}
+	}
+
+	protect := func(h http.Handler) http.Handler { return h }
+	if *oidcIssuerPtr != "" {
+		validator, err := httpauth.NewOIDCValidator(*oidcIssuerPtr, *oidcAudiencePtr, *oidcJWKSRefreshPtr)
+		if err != nil {
+			log.Error.Fatalln("Could not start the OIDC validator:", err)
+		}
+		protect = validator.Middleware
+	}
+
+	http.Handle("/metrics", protect(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+	http.Handle("/fbx/token", protect(collector.TokenAdminHandler()))
+
 	log.Info.Println("Listen to", *listenPtr)
-	log.Error.Println(http.ListenAndServe(*listenPtr, nil))
+	if *tlsCertPtr != "" || *tlsKeyPtr != "" {
+		tlsConfig, err := httpauth.NewServerTLSConfig(*clientCAPtr)
+		if err != nil {
+			log.Error.Fatalln("Could not build the server TLS config:", err)
+		}
+		server := &http.Server{
+			Addr:      *listenPtr,
+			TLSConfig: tlsConfig,
+		}
+		log.Error.Println(server.ListenAndServeTLS(*tlsCertPtr, *tlsKeyPtr))
+	} else {
+		log.Error.Println(http.ListenAndServe(*listenPtr, nil))
+	}
 }